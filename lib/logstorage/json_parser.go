@@ -1,7 +1,10 @@
 package logstorage
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"strconv"
 	"sync"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
@@ -21,6 +24,12 @@ type JSONParser struct {
 	// or until the parser is returned to the pool with PutParser() call.
 	Fields []Field
 
+	// ArrayMode controls how JSON arrays are converted into Fields.
+	//
+	// It defaults to ArrayString, which preserves the historical behavior
+	// of marshaling arrays back to their JSON string representation.
+	ArrayMode ArrayMode
+
 	// p is used for fast JSON parsing
 	p fastjson.Parser
 
@@ -30,12 +39,17 @@ type JSONParser struct {
 	// prefixBuf is used for holding the current key prefix
 	// when it is composed from multiple keys.
 	prefixBuf []byte
+
+	// streamBuf is a scratch buffer reused across ParseLogMessageStream field
+	// callbacks for serializing nested objects and arrays encountered in the stream.
+	streamBuf []byte
 }
 
 func (p *JSONParser) reset() {
 	p.resetNobuf()
 
 	p.buf = p.buf[:0]
+	p.streamBuf = p.streamBuf[:0]
 }
 
 func (p *JSONParser) resetNobuf() {
@@ -66,6 +80,27 @@ func PutJSONParser(p *JSONParser) {
 
 var parserPool sync.Pool
 
+// ArrayMode controls how JSONParser.ParseLogMessage(NoResetBuf) converts JSON arrays into Fields.
+type ArrayMode int
+
+const (
+	// ArrayString marshals the whole array back to its JSON string representation.
+	//
+	// For example, {"tags":["a","b"]} is converted to the single field tags=["a","b"].
+	// This is the default and matches the historical behavior of JSONParser.
+	ArrayString ArrayMode = iota
+
+	// ArrayFlatten emits a repeated field with the same name for every array element.
+	//
+	// For example, {"tags":["a","b"]} is converted to two fields: tags=a and tags=b.
+	ArrayFlatten
+
+	// ArrayIndex emits an indexed field for every array element.
+	//
+	// For example, {"tags":["a","b"]} is converted to two fields: tags.0=a and tags.1=b.
+	ArrayIndex
+)
+
 // ParseLogMessageNoResetBuf parses the given JSON log message msg into p.Fields.
 //
 // It adds the given prefix to all the parsed field names.
@@ -99,7 +134,7 @@ func (p *JSONParser) parseLogMessage(msg, prefix string, resetBuf bool) error {
 		p.resetNobuf()
 	}
 	p.prefixBuf = append(p.prefixBuf[:0], prefix...)
-	p.Fields, p.buf, p.prefixBuf = appendLogFields(p.Fields, p.buf, p.prefixBuf, v)
+	p.Fields, p.buf, p.prefixBuf = appendLogFields(p.Fields, p.buf, p.prefixBuf, v, p.ArrayMode)
 	return nil
 }
 
@@ -118,7 +153,291 @@ func (p *JSONParser) RenameField(oldName, newName string) {
 	}
 }
 
-func appendLogFields(dst []Field, dstBuf, prefixBuf []byte, v *fastjson.Value) ([]Field, []byte, []byte) {
+// ParseLogMessageStream parses NDJSON data (or a single, possibly huge, JSON
+// object) read from r and invokes fn for every flattened field as soon as it
+// is decoded, instead of collecting them into p.Fields like ParseLogMessage does.
+//
+// It adds the given prefix to all the parsed field names. It honors
+// p.ArrayMode the same way ParseLogMessage does.
+//
+// The Field passed to fn is valid only until fn returns - do not retain it.
+// If r contains more than one JSON object (NDJSON), fn is invoked for the
+// fields of every object in order.
+//
+// Unlike ParseLogMessage, ParseLogMessageStream doesn't buffer the whole
+// message in memory - it relies on json.Decoder's token-based API and a
+// single reused scratch buffer, so peak memory usage is O(nesting depth)
+// instead of O(len(msg)), as long as p.ArrayMode is ArrayFlatten or ArrayIndex.
+// With the default ArrayMode (ArrayString), or for arrays nested inside
+// arrays, the whole array is still buffered in order to produce its JSON
+// string representation, so peak memory for those is O(array size).
+func (p *JSONParser) ParseLogMessageStream(r io.Reader, prefix string, fn func(Field) error) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	for dec.More() {
+		p.resetNobuf()
+		p.prefixBuf = append(p.prefixBuf[:0], prefix...)
+		if err := p.decodeObjectStream(dec, fn); err != nil {
+			return fmt.Errorf("cannot parse json: %w", err)
+		}
+	}
+	return nil
+}
+
+func (p *JSONParser) decodeObjectStream(dec *json.Decoder, fn func(Field) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("expecting json dictionary; got %v", tok)
+	}
+	return p.decodeObjectFieldsStream(dec, fn)
+}
+
+func (p *JSONParser) decodeObjectFieldsStream(dec *json.Decoder, fn func(Field) error) error {
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := keyTok.(string)
+		if !ok {
+			return fmt.Errorf("unexpected non-string json object key: %v", keyTok)
+		}
+		if err := p.decodeValueStream(dec, key, fn); err != nil {
+			return err
+		}
+	}
+	// Consume the closing '}'.
+	_, err := dec.Token()
+	return err
+}
+
+func (p *JSONParser) decodeValueStream(dec *json.Decoder, key string, fn func(Field) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			// Flatten nested JSON objects, like appendLogFields does.
+			prefixLen := len(p.prefixBuf)
+			p.prefixBuf = append(p.prefixBuf, key...)
+			p.prefixBuf = append(p.prefixBuf, '.')
+			err := p.decodeObjectFieldsStream(dec, fn)
+			p.prefixBuf = p.prefixBuf[:prefixLen]
+			return err
+		case '[':
+			return p.decodeArrayStream(dec, key, fn)
+		default:
+			return fmt.Errorf("unexpected json delimiter: %v", t)
+		}
+	case nil:
+		// Skip nulls, like appendLogFields does.
+		return nil
+	case string:
+		return p.emitFieldStream(key, t, fn)
+	case json.Number:
+		return p.emitFieldStream(key, t.String(), fn)
+	case bool:
+		return p.emitFieldStream(key, strconv.FormatBool(t), fn)
+	default:
+		return fmt.Errorf("unexpected json value type for key %q: %T", key, tok)
+	}
+}
+
+func (p *JSONParser) emitFieldStream(key, value string, fn func(Field) error) error {
+	p.buf = append(p.buf[:0], p.prefixBuf...)
+	p.buf = append(p.buf, key...)
+	// p.buf is reused by the very next field/record, so the Name passed to fn
+	// must be a real copy - unlike p.Fields in the non-streaming parser, the
+	// caller has no way to know when it is safe to keep referencing our buffer.
+	return fn(Field{
+		Name:  string(p.buf),
+		Value: value,
+	})
+}
+
+// decodeArrayStream decodes the JSON array value of key, honoring p.ArrayMode
+// the same way appendArrayLogFields does for the non-streaming parser.
+func (p *JSONParser) decodeArrayStream(dec *json.Decoder, key string, fn func(Field) error) error {
+	if p.ArrayMode == ArrayString {
+		p.streamBuf = p.streamBuf[:0]
+		p.streamBuf = append(p.streamBuf, '[')
+		streamBuf, err := appendJSONArrayItemsStream(p.streamBuf, dec)
+		p.streamBuf = streamBuf
+		if err != nil {
+			return err
+		}
+		p.streamBuf = append(p.streamBuf, ']')
+		return p.emitFieldStream(key, string(p.streamBuf), fn)
+	}
+
+	for i := 0; dec.More(); i++ {
+		if err := p.decodeArrayItemStream(dec, key, i, fn); err != nil {
+			return err
+		}
+	}
+	// Consume the closing ']'.
+	_, err := dec.Token()
+	return err
+}
+
+func (p *JSONParser) decodeArrayItemStream(dec *json.Decoder, key string, i int, fn func(Field) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			prefixLen := len(p.prefixBuf)
+			p.prefixBuf = append(p.prefixBuf, key...)
+			if p.ArrayMode == ArrayIndex {
+				p.prefixBuf = append(p.prefixBuf, '.')
+				p.prefixBuf = strconv.AppendInt(p.prefixBuf, int64(i), 10)
+			}
+			p.prefixBuf = append(p.prefixBuf, '.')
+			err := p.decodeObjectFieldsStream(dec, fn)
+			p.prefixBuf = p.prefixBuf[:prefixLen]
+			return err
+		case '[':
+			// Arrays nested inside arrays keep the legacy string representation
+			// regardless of p.ArrayMode, like appendArrayLogFields does.
+			p.streamBuf = p.streamBuf[:0]
+			p.streamBuf = append(p.streamBuf, '[')
+			streamBuf, err := appendJSONArrayItemsStream(p.streamBuf, dec)
+			p.streamBuf = streamBuf
+			if err != nil {
+				return err
+			}
+			p.streamBuf = append(p.streamBuf, ']')
+			return p.emitArrayItemFieldStream(key, i, string(p.streamBuf), fn)
+		default:
+			return fmt.Errorf("unexpected json delimiter: %v", t)
+		}
+	case nil:
+		// Skip nulls, like appendArrayLogFields does.
+		return nil
+	case string:
+		return p.emitArrayItemFieldStream(key, i, t, fn)
+	case json.Number:
+		return p.emitArrayItemFieldStream(key, i, t.String(), fn)
+	case bool:
+		return p.emitArrayItemFieldStream(key, i, strconv.FormatBool(t), fn)
+	default:
+		return fmt.Errorf("unexpected json value type for key %q: %T", key, tok)
+	}
+}
+
+// emitArrayItemFieldStream emits the i-th element of array field key, naming
+// it key (ArrayFlatten) or key.i (ArrayIndex).
+func (p *JSONParser) emitArrayItemFieldStream(key string, i int, value string, fn func(Field) error) error {
+	if p.ArrayMode != ArrayIndex {
+		return p.emitFieldStream(key, value, fn)
+	}
+	p.buf = append(p.buf[:0], p.prefixBuf...)
+	p.buf = append(p.buf, key...)
+	p.buf = append(p.buf, '.')
+	p.buf = strconv.AppendInt(p.buf, int64(i), 10)
+	// See the comment in emitFieldStream on why this must be a real copy.
+	return fn(Field{
+		Name:  string(p.buf),
+		Value: value,
+	})
+}
+
+// appendJSONArrayItemsStream appends the comma-separated JSON text of the
+// array items read from dec to dst, stopping right before the closing ']',
+// which the caller must consume.
+func appendJSONArrayItemsStream(dst []byte, dec *json.Decoder) ([]byte, error) {
+	first := true
+	for dec.More() {
+		if !first {
+			dst = append(dst, ',')
+		}
+		first = false
+		var err error
+		dst, err = appendJSONValueStream(dst, dec)
+		if err != nil {
+			return dst, err
+		}
+	}
+	// Consume the closing ']'.
+	if _, err := dec.Token(); err != nil {
+		return dst, err
+	}
+	return dst, nil
+}
+
+// appendJSONValueStream reads a single JSON value (scalar, object or array)
+// from dec and appends its JSON text representation to dst.
+func appendJSONValueStream(dst []byte, dec *json.Decoder) ([]byte, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return dst, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			dst = append(dst, '{')
+			first := true
+			for dec.More() {
+				if !first {
+					dst = append(dst, ',')
+				}
+				first = false
+				keyTok, err := dec.Token()
+				if err != nil {
+					return dst, err
+				}
+				key, ok := keyTok.(string)
+				if !ok {
+					return dst, fmt.Errorf("unexpected non-string json object key: %v", keyTok)
+				}
+				dst = strconv.AppendQuote(dst, key)
+				dst = append(dst, ':')
+				dst, err = appendJSONValueStream(dst, dec)
+				if err != nil {
+					return dst, err
+				}
+			}
+			if _, err := dec.Token(); err != nil { // consume closing '}'
+				return dst, err
+			}
+			dst = append(dst, '}')
+			return dst, nil
+		case '[':
+			dst = append(dst, '[')
+			dst, err = appendJSONArrayItemsStream(dst, dec)
+			if err != nil {
+				return dst, err
+			}
+			dst = append(dst, ']')
+			return dst, nil
+		default:
+			return dst, fmt.Errorf("unexpected json delimiter: %v", t)
+		}
+	case string:
+		return strconv.AppendQuote(dst, t), nil
+	case json.Number:
+		return append(dst, t.String()...), nil
+	case bool:
+		return strconv.AppendBool(dst, t), nil
+	case nil:
+		return append(dst, "null"...), nil
+	default:
+		return dst, fmt.Errorf("unexpected json token: %v", tok)
+	}
+}
+
+func appendLogFields(dst []Field, dstBuf, prefixBuf []byte, v *fastjson.Value, arrayMode ArrayMode) ([]Field, []byte, []byte) {
 	o := v.GetObject()
 	o.Visit(func(k []byte, v *fastjson.Value) {
 		t := v.Type()
@@ -131,10 +450,20 @@ func appendLogFields(dst []Field, dstBuf, prefixBuf []byte, v *fastjson.Value) (
 			prefixLen := len(prefixBuf)
 			prefixBuf = append(prefixBuf, k...)
 			prefixBuf = append(prefixBuf, '.')
-			dst, dstBuf, prefixBuf = appendLogFields(dst, dstBuf, prefixBuf, v)
+			dst, dstBuf, prefixBuf = appendLogFields(dst, dstBuf, prefixBuf, v, arrayMode)
 			prefixBuf = prefixBuf[:prefixLen]
-		case fastjson.TypeArray, fastjson.TypeNumber, fastjson.TypeTrue, fastjson.TypeFalse:
-			// Convert JSON arrays, numbers, true and false values to their string representation
+		case fastjson.TypeArray:
+			if arrayMode == ArrayString {
+				// Convert the whole JSON array to its string representation.
+				dstBufLen := len(dstBuf)
+				dstBuf = v.MarshalTo(dstBuf)
+				value := dstBuf[dstBufLen:]
+				dst, dstBuf = appendLogField(dst, dstBuf, prefixBuf, k, value)
+			} else {
+				dst, dstBuf, prefixBuf = appendArrayLogFields(dst, dstBuf, prefixBuf, k, v, arrayMode)
+			}
+		case fastjson.TypeNumber, fastjson.TypeTrue, fastjson.TypeFalse:
+			// Convert JSON numbers, true and false values to their string representation
 			dstBufLen := len(dstBuf)
 			dstBuf = v.MarshalTo(dstBuf)
 			value := dstBuf[dstBufLen:]
@@ -152,6 +481,64 @@ func appendLogFields(dst []Field, dstBuf, prefixBuf []byte, v *fastjson.Value) (
 	return dst, dstBuf, prefixBuf
 }
 
+// appendArrayLogFields flattens the JSON array v (the value of key k) into dst
+// according to arrayMode, which is either ArrayFlatten or ArrayIndex.
+//
+// Nested objects inside the array recurse using the same prefix scheme as
+// appendLogFields, and nested arrays fall back to their JSON string
+// representation, since there is no unambiguous flat field name for them.
+func appendArrayLogFields(dst []Field, dstBuf, prefixBuf []byte, k []byte, v *fastjson.Value, arrayMode ArrayMode) ([]Field, []byte, []byte) {
+	for i, item := range v.GetArray() {
+		t := item.Type()
+		switch t {
+		case fastjson.TypeNull:
+			// Skip nulls, like appendLogFields does.
+		case fastjson.TypeObject:
+			prefixLen := len(prefixBuf)
+			prefixBuf = append(prefixBuf, k...)
+			if arrayMode == ArrayIndex {
+				prefixBuf = append(prefixBuf, '.')
+				prefixBuf = strconv.AppendInt(prefixBuf, int64(i), 10)
+			}
+			prefixBuf = append(prefixBuf, '.')
+			dst, dstBuf, prefixBuf = appendLogFields(dst, dstBuf, prefixBuf, item, arrayMode)
+			prefixBuf = prefixBuf[:prefixLen]
+		case fastjson.TypeArray:
+			dstBufLen := len(dstBuf)
+			dstBuf = item.MarshalTo(dstBuf)
+			value := dstBuf[dstBufLen:]
+			dst, dstBuf = appendArrayItemField(dst, dstBuf, prefixBuf, k, i, value, arrayMode)
+		case fastjson.TypeNumber, fastjson.TypeTrue, fastjson.TypeFalse:
+			dstBufLen := len(dstBuf)
+			dstBuf = item.MarshalTo(dstBuf)
+			value := dstBuf[dstBufLen:]
+			dst, dstBuf = appendArrayItemField(dst, dstBuf, prefixBuf, k, i, value, arrayMode)
+		case fastjson.TypeString:
+			dstBufLen := len(dstBuf)
+			dstBuf = append(dstBuf, item.GetStringBytes()...)
+			value := dstBuf[dstBufLen:]
+			dst, dstBuf = appendArrayItemField(dst, dstBuf, prefixBuf, k, i, value, arrayMode)
+		default:
+			logger.Panicf("BUG: unexpected JSON type: %s", t)
+		}
+	}
+	return dst, dstBuf, prefixBuf
+}
+
+// appendArrayItemField appends the Field for the i-th element of array field k,
+// naming it k (ArrayFlatten) or k.i (ArrayIndex).
+func appendArrayItemField(dst []Field, dstBuf, prefixBuf, k []byte, i int, value []byte, arrayMode ArrayMode) ([]Field, []byte) {
+	if arrayMode != ArrayIndex {
+		return appendLogField(dst, dstBuf, prefixBuf, k, value)
+	}
+	keyBufLen := len(dstBuf)
+	dstBuf = append(dstBuf, k...)
+	dstBuf = append(dstBuf, '.')
+	dstBuf = strconv.AppendInt(dstBuf, int64(i), 10)
+	itemKey := dstBuf[keyBufLen:]
+	return appendLogField(dst, dstBuf, prefixBuf, itemKey, value)
+}
+
 func appendLogField(dst []Field, dstBuf, prefixBuf, k, value []byte) ([]Field, []byte) {
 	dstBufLen := len(dstBuf)
 	dstBuf = append(dstBuf, prefixBuf...)
@@ -0,0 +1,70 @@
+package logstorage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJSONParserArrayMode(t *testing.T) {
+	f := func(arrayMode ArrayMode, msg string, fieldsExpected []Field) {
+		t.Helper()
+
+		p := GetJSONParser()
+		defer PutJSONParser(p)
+		p.ArrayMode = arrayMode
+
+		if err := p.ParseLogMessage([]byte(msg), ""); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(p.Fields, fieldsExpected) {
+			t.Fatalf("unexpected fields;\ngot\n%v\nwant\n%v", p.Fields, fieldsExpected)
+		}
+	}
+
+	// ArrayString (default) - arrays are marshaled back to their JSON string representation.
+	f(ArrayString, `{"tags":["a","b","c"]}`, []Field{
+		{Name: "tags", Value: `["a","b","c"]`},
+	})
+
+	// ArrayFlatten - repeated fields with the same name.
+	f(ArrayFlatten, `{"tags":["a","b","c"]}`, []Field{
+		{Name: "tags", Value: "a"},
+		{Name: "tags", Value: "b"},
+		{Name: "tags", Value: "c"},
+	})
+
+	// ArrayIndex - indexed fields.
+	f(ArrayIndex, `{"tags":["a","b","c"]}`, []Field{
+		{Name: "tags.0", Value: "a"},
+		{Name: "tags.1", Value: "b"},
+		{Name: "tags.2", Value: "c"},
+	})
+
+	// ArrayFlatten with nested objects - they recurse using the normal object-flattening prefix.
+	f(ArrayFlatten, `{"items":[{"id":"1"},{"id":"2"}]}`, []Field{
+		{Name: "items.id", Value: "1"},
+		{Name: "items.id", Value: "2"},
+	})
+
+	// ArrayIndex with nested objects - the index is inserted into the prefix.
+	f(ArrayIndex, `{"items":[{"id":"1"},{"id":"2"}]}`, []Field{
+		{Name: "items.0.id", Value: "1"},
+		{Name: "items.1.id", Value: "2"},
+	})
+
+	// Arrays of arrays fall back to their JSON string representation regardless of arrayMode.
+	f(ArrayFlatten, `{"matrix":[[1,2],[3,4]]}`, []Field{
+		{Name: "matrix", Value: "[1,2]"},
+		{Name: "matrix", Value: "[3,4]"},
+	})
+	f(ArrayIndex, `{"matrix":[[1,2],[3,4]]}`, []Field{
+		{Name: "matrix.0", Value: "[1,2]"},
+		{Name: "matrix.1", Value: "[3,4]"},
+	})
+
+	// Nulls inside arrays are skipped, like top-level nulls.
+	f(ArrayFlatten, `{"tags":["a",null,"b"]}`, []Field{
+		{Name: "tags", Value: "a"},
+		{Name: "tags", Value: "b"},
+	})
+}
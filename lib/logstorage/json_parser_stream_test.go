@@ -0,0 +1,104 @@
+package logstorage
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONParserParseLogMessageStream(t *testing.T) {
+	f := func(arrayMode ArrayMode, msg string, fieldsExpected []Field) {
+		t.Helper()
+
+		p := GetJSONParser()
+		defer PutJSONParser(p)
+		p.ArrayMode = arrayMode
+
+		var got []Field
+		err := p.ParseLogMessageStream(strings.NewReader(msg), "", func(f Field) error {
+			got = append(got, Field{Name: f.Name, Value: f.Value})
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(got, fieldsExpected) {
+			t.Fatalf("unexpected fields;\ngot\n%v\nwant\n%v", got, fieldsExpected)
+		}
+	}
+
+	f(ArrayString, `{"foo":"bar","baz":{"x":1,"y":true}}`, []Field{
+		{Name: "foo", Value: "bar"},
+		{Name: "baz.x", Value: "1"},
+		{Name: "baz.y", Value: "true"},
+	})
+
+	// Arrays are stringified by default, same as the non-streaming parser.
+	f(ArrayString, `{"tags":["a","b"]}`, []Field{
+		{Name: "tags", Value: `["a","b"]`},
+	})
+
+	// ArrayMode is honored by the streaming parser too.
+	f(ArrayFlatten, `{"tags":["a","b"]}`, []Field{
+		{Name: "tags", Value: "a"},
+		{Name: "tags", Value: "b"},
+	})
+	f(ArrayIndex, `{"tags":["a","b"]}`, []Field{
+		{Name: "tags.0", Value: "a"},
+		{Name: "tags.1", Value: "b"},
+	})
+	f(ArrayFlatten, `{"items":[{"id":"1"},{"id":"2"}]}`, []Field{
+		{Name: "items.id", Value: "1"},
+		{Name: "items.id", Value: "2"},
+	})
+	f(ArrayIndex, `{"matrix":[[1,2],[3,4]]}`, []Field{
+		{Name: "matrix.0", Value: "[1,2]"},
+		{Name: "matrix.1", Value: "[3,4]"},
+	})
+
+	// NDJSON - multiple top-level objects are all visited, in order.
+	f(ArrayString, "{\"a\":\"1\"}\n{\"a\":\"2\"}\n", []Field{
+		{Name: "a", Value: "1"},
+		{Name: "a", Value: "2"},
+	})
+}
+
+func TestJSONParserParseLogMessageStreamMatchesParseLogMessage(t *testing.T) {
+	msgs := []string{
+		`{"foo":"bar"}`,
+		`{"a":{"b":{"c":"d"}}}`,
+		`{"n":123,"t":true,"f":false,"s":null}`,
+		`{"tags":["x","y","z"]}`,
+		`{"items":[{"id":"1","tags":["a"]},{"id":"2"}]}`,
+	}
+	modes := []ArrayMode{ArrayString, ArrayFlatten, ArrayIndex}
+
+	for _, msg := range msgs {
+		for _, arrayMode := range modes {
+			p := GetJSONParser()
+			p.ArrayMode = arrayMode
+			if err := p.ParseLogMessage([]byte(msg), "prefix."); err != nil {
+				PutJSONParser(p)
+				t.Fatalf("ParseLogMessage(%q) failed: %s", msg, err)
+			}
+			want := append([]Field{}, p.Fields...)
+			PutJSONParser(p)
+
+			p = GetJSONParser()
+			p.ArrayMode = arrayMode
+			var got []Field
+			err := p.ParseLogMessageStream(strings.NewReader(msg), "prefix.", func(f Field) error {
+				got = append(got, Field{Name: f.Name, Value: f.Value})
+				return nil
+			})
+			PutJSONParser(p)
+			if err != nil {
+				t.Fatalf("ParseLogMessageStream(%q) failed: %s", msg, err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("streaming and non-streaming parsers disagree for %q with arrayMode=%v;\nstream: %v\nbatch:  %v", msg, arrayMode, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package logstorage
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/valyala/fastjson"
+)
+
+// OTLPJSONParser parses OTLP/JSON logs payloads - the JSON encoding of
+// opentelemetry.proto.logs.v1.LogsData - into per-log-record Fields.
+//
+// See https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+//
+// Use GetOTLPJSONParser() for obtaining the parser.
+type OTLPJSONParser struct {
+	// p is used for fast JSON parsing
+	p fastjson.Parser
+
+	// buf is used for holding the backing data for the Fields passed to ParseLogsData callbacks
+	buf []byte
+
+	// fields is reused across parseLogRecord calls to avoid allocating
+	// a new []Field backing array for every log record.
+	fields []Field
+}
+
+func (p *OTLPJSONParser) reset() {
+	p.buf = p.buf[:0]
+
+	clear(p.fields)
+	p.fields = p.fields[:0]
+}
+
+// GetOTLPJSONParser returns OTLPJSONParser ready to parse OTLP/JSON logs payloads.
+//
+// Return the parser to the pool when it is no longer needed by calling PutOTLPJSONParser().
+func GetOTLPJSONParser() *OTLPJSONParser {
+	v := otlpParserPool.Get()
+	if v == nil {
+		return &OTLPJSONParser{}
+	}
+	return v.(*OTLPJSONParser)
+}
+
+// PutOTLPJSONParser returns the parser to the pool.
+//
+// The parser cannot be used after returning to the pool.
+func PutOTLPJSONParser(p *OTLPJSONParser) {
+	p.reset()
+	otlpParserPool.Put(p)
+}
+
+var otlpParserPool sync.Pool
+
+// ParseLogsData parses the OTLP/JSON logs payload data and invokes fn for every
+// logRecord found in it, in the order resourceLogs[].scopeLogs[].logRecords[] appear.
+//
+// msgField is the name of the Field the log record's body is written to.
+//
+// timestampNsecs passed to fn is parsed from the log record's timeUnixNano;
+// it is 0 if the log record doesn't contain a valid timeUnixNano.
+//
+// The fields slice passed to fn is valid only until fn returns or ParseLogsData
+// is called again - do not retain it.
+func (p *OTLPJSONParser) ParseLogsData(data []byte, msgField string, fn func(timestampNsecs int64, fields []Field) error) error {
+	v, err := p.p.ParseBytes(data)
+	if err != nil {
+		return fmt.Errorf("cannot parse OTLP/JSON logs payload: %w", err)
+	}
+	if t := v.Type(); t != fastjson.TypeObject {
+		return fmt.Errorf("expecting OTLP/JSON logs dictionary; got %s", t)
+	}
+	for _, rl := range v.GetArray("resourceLogs") {
+		resource := rl.Get("resource", "attributes")
+		for _, sl := range rl.GetArray("scopeLogs") {
+			scope := sl.Get("scope")
+			for _, lr := range sl.GetArray("logRecords") {
+				if err := p.parseLogRecord(resource, scope, lr, msgField, fn); err != nil {
+					return fmt.Errorf("cannot parse OTLP/JSON logRecord: %w", err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (p *OTLPJSONParser) parseLogRecord(resourceAttrs *fastjson.Value, scope, lr *fastjson.Value, msgField string, fn func(int64, []Field) error) error {
+	p.reset()
+	fields := p.fields
+
+	fields = p.appendAttributes(fields, resourceAttrs, "resource.")
+	if scope != nil {
+		fields = p.appendAttributes(fields, scope.Get("attributes"), "scope.")
+		if name := scope.GetStringBytes("name"); len(name) > 0 {
+			fields, p.buf = appendLogField(fields, p.buf, nil, []byte("scope.name"), name)
+		}
+		if version := scope.GetStringBytes("version"); len(version) > 0 {
+			fields, p.buf = appendLogField(fields, p.buf, nil, []byte("scope.version"), version)
+		}
+	}
+
+	fields = p.appendAttributes(fields, lr.Get("attributes"), "")
+
+	if sevText := lr.GetStringBytes("severityText"); len(sevText) > 0 {
+		fields, p.buf = appendLogField(fields, p.buf, nil, []byte("severityText"), sevText)
+	}
+	if lr.Exists("severityNumber") {
+		bufLen := len(p.buf)
+		p.buf = lr.Get("severityNumber").MarshalTo(p.buf)
+		fields, p.buf = appendLogField(fields, p.buf, nil, []byte("severityNumber"), p.buf[bufLen:])
+	}
+	if traceID := lr.GetStringBytes("traceId"); len(traceID) > 0 {
+		fields, p.buf = appendLogField(fields, p.buf, nil, []byte("traceId"), traceID)
+	}
+	if spanID := lr.GetStringBytes("spanId"); len(spanID) > 0 {
+		fields, p.buf = appendLogField(fields, p.buf, nil, []byte("spanId"), spanID)
+	}
+
+	if body := lr.Get("body"); body != nil {
+		bufLen := len(p.buf)
+		p.buf = appendOTLPAnyValue(p.buf, body)
+		fields, p.buf = appendLogField(fields, p.buf, nil, []byte(msgField), p.buf[bufLen:])
+	}
+
+	p.fields = fields
+
+	var timestampNsecs int64
+	if ts := lr.GetStringBytes("timeUnixNano"); len(ts) > 0 {
+		// timeUnixNano is encoded as a JSON string in OTLP/JSON to avoid precision loss.
+		n, err := strconv.ParseInt(bytesutil.ToUnsafeString(ts), 10, 64)
+		if err == nil {
+			timestampNsecs = n
+		}
+	}
+
+	return fn(timestampNsecs, fields)
+}
+
+// appendAttributes flattens the OTLP `attributes` array attrs (a repeated
+// KeyValue message) into dst, prefixing every key with prefix.
+func (p *OTLPJSONParser) appendAttributes(dst []Field, attrs *fastjson.Value, prefix string) []Field {
+	for _, kv := range attrs.GetArray() {
+		key := kv.GetStringBytes("key")
+		bufLen := len(p.buf)
+		p.buf = appendOTLPAnyValue(p.buf, kv.Get("value"))
+		value := p.buf[bufLen:]
+		dst, p.buf = appendLogField(dst, p.buf, bytesutil.ToUnsafeBytes(prefix), key, value)
+	}
+	return dst
+}
+
+// appendOTLPAnyValue appends the string representation of an OTLP AnyValue v
+// to dst and returns the extended dst.
+func appendOTLPAnyValue(dst []byte, v *fastjson.Value) []byte {
+	if v == nil {
+		return dst
+	}
+	switch {
+	case v.Exists("stringValue"):
+		return append(dst, v.GetStringBytes("stringValue")...)
+	case v.Exists("intValue"):
+		// intValue is encoded as a JSON string in OTLP/JSON to avoid precision loss.
+		return append(dst, v.GetStringBytes("intValue")...)
+	case v.Exists("doubleValue"):
+		return v.Get("doubleValue").MarshalTo(dst)
+	case v.Exists("boolValue"):
+		return v.Get("boolValue").MarshalTo(dst)
+	default:
+		// arrayValue, kvlistValue, bytesValue and anything else: fall back
+		// to the raw JSON representation, like JSONParser.ArrayString does.
+		return v.MarshalTo(dst)
+	}
+}
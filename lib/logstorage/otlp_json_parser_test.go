@@ -0,0 +1,162 @@
+package logstorage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOTLPJSONParserParseLogsData(t *testing.T) {
+	data := `{
+		"resourceLogs": [{
+			"resource": {
+				"attributes": [
+					{"key": "service.name", "value": {"stringValue": "my-service"}}
+				]
+			},
+			"scopeLogs": [{
+				"scope": {
+					"name": "my-scope",
+					"version": "1.0",
+					"attributes": [
+						{"key": "scope.attr", "value": {"intValue": "42"}}
+					]
+				},
+				"logRecords": [{
+					"timeUnixNano": "1700000000000000000",
+					"severityText": "INFO",
+					"severityNumber": 9,
+					"traceId": "trace-1",
+					"spanId": "span-1",
+					"body": {"stringValue": "hello world"},
+					"attributes": [
+						{"key": "http.method", "value": {"stringValue": "GET"}},
+						{"key": "enabled", "value": {"boolValue": true}}
+					]
+				}]
+			}]
+		}]
+	}`
+
+	p := GetOTLPJSONParser()
+	defer PutOTLPJSONParser(p)
+
+	var gotTimestamp int64
+	var gotFields []Field
+	callCount := 0
+	err := p.ParseLogsData([]byte(data), "_msg", func(timestampNsecs int64, fields []Field) error {
+		callCount++
+		gotTimestamp = timestampNsecs
+		gotFields = append([]Field{}, fields...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if callCount != 1 {
+		t.Fatalf("unexpected number of logRecords visited: %d", callCount)
+	}
+	if gotTimestamp != 1700000000000000000 {
+		t.Fatalf("unexpected timestamp: %d", gotTimestamp)
+	}
+
+	fieldsExpected := []Field{
+		{Name: "resource.service.name", Value: "my-service"},
+		{Name: "scope.scope.attr", Value: "42"},
+		{Name: "scope.name", Value: "my-scope"},
+		{Name: "scope.version", Value: "1.0"},
+		{Name: "http.method", Value: "GET"},
+		{Name: "enabled", Value: "true"},
+		{Name: "severityText", Value: "INFO"},
+		{Name: "severityNumber", Value: "9"},
+		{Name: "traceId", Value: "trace-1"},
+		{Name: "spanId", Value: "span-1"},
+		{Name: "_msg", Value: "hello world"},
+	}
+	if !reflect.DeepEqual(gotFields, fieldsExpected) {
+		t.Fatalf("unexpected fields;\ngot\n%v\nwant\n%v", gotFields, fieldsExpected)
+	}
+}
+
+func TestOTLPJSONParserMissingOrInvalidTimeUnixNano(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+
+		p := GetOTLPJSONParser()
+		defer PutOTLPJSONParser(p)
+
+		var gotTimestamp int64
+		called := false
+		err := p.ParseLogsData([]byte(data), "_msg", func(timestampNsecs int64, fields []Field) error {
+			called = true
+			gotTimestamp = timestampNsecs
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !called {
+			t.Fatalf("expecting the logRecord callback to be invoked")
+		}
+		if gotTimestamp != 0 {
+			t.Fatalf("expecting timestampNsecs=0 for missing/invalid timeUnixNano; got %d", gotTimestamp)
+		}
+	}
+
+	// No timeUnixNano field at all.
+	f(`{"resourceLogs":[{"scopeLogs":[{"logRecords":[{"body":{"stringValue":"x"}}]}]}]}`)
+
+	// Non-numeric timeUnixNano.
+	f(`{"resourceLogs":[{"scopeLogs":[{"logRecords":[{"timeUnixNano":"not-a-number","body":{"stringValue":"x"}}]}]}]}`)
+}
+
+func TestOTLPJSONParserRejectsNonObjectRoot(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+
+		p := GetOTLPJSONParser()
+		defer PutOTLPJSONParser(p)
+
+		err := p.ParseLogsData([]byte(data), "_msg", func(_ int64, _ []Field) error {
+			t.Fatalf("the logRecord callback must not be invoked for %q", data)
+			return nil
+		})
+		if err == nil {
+			t.Fatalf("expecting non-nil error for a non-object OTLP/JSON root: %q", data)
+		}
+	}
+
+	f(`null`)
+	f(`42`)
+	f(`[]`)
+	f(`"resourceLogs"`)
+}
+
+func TestOTLPJSONParserReusesFieldsBackingArray(t *testing.T) {
+	data := `{"resourceLogs":[{"scopeLogs":[{"logRecords":[
+		{"body":{"stringValue":"first"}},
+		{"body":{"stringValue":"second"}}
+	]}]}]}`
+
+	p := GetOTLPJSONParser()
+	defer PutOTLPJSONParser(p)
+
+	var capacities []int
+	var firstFields []*Field
+	err := p.ParseLogsData([]byte(data), "_msg", func(_ int64, fields []Field) error {
+		capacities = append(capacities, cap(fields))
+		firstFields = append(firstFields, &fields[0])
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(capacities) != 2 {
+		t.Fatalf("unexpected number of logRecords visited: %d", len(capacities))
+	}
+	if firstFields[0] != firstFields[1] {
+		t.Fatalf("expecting the same fields backing array to be reused for every logRecord")
+	}
+	if p.fields == nil {
+		t.Fatalf("expecting p.fields to be populated after ParseLogsData")
+	}
+}
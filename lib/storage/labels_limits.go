@@ -1,10 +1,18 @@
 package storage
 
 import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"github.com/VictoriaMetrics/metrics"
+	"gopkg.in/yaml.v2"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
@@ -15,6 +23,11 @@ import (
 // Samples with longer names are ignored.
 const maxLabelNameLen = 256
 
+// truncatedSuffix is appended to label names and values which got truncated
+// in the "soft" tenant limits mode, if the limit is longer than the suffix itself;
+// otherwise they are truncated without it. See TenantLabelLimits.Mode.
+const truncatedSuffix = "…truncated"
+
 var (
 	// The maximum number of labels per each timeseries.
 	//
@@ -27,8 +40,11 @@ var (
 	maxLabelValueLen = 4 * 1024
 )
 
-// InitLabelsLimits inits labels limits and metrics for them
-func InitLabelsLimits(inputMaxLabelsPerTimeseries, inputMaxLabelValueLen int) {
+// InitLabelsLimits inits labels limits and metrics for them.
+//
+// If tenantOverridesPath is non-empty, it is loaded as a TenantLabelLimitsConfig
+// YAML file and re-loaded every time the process receives SIGHUP.
+func InitLabelsLimits(inputMaxLabelsPerTimeseries, inputMaxLabelValueLen int, tenantOverridesPath string) {
 	maxLabelsPerTimeseries = inputMaxLabelsPerTimeseries
 	maxLabelValueLen = inputMaxLabelValueLen
 
@@ -41,6 +57,15 @@ func InitLabelsLimits(inputMaxLabelsPerTimeseries, inputMaxLabelValueLen int) {
 	_ = metrics.NewGauge(`vm_rows_ignored_total{reason="too_long_label_value"}`, func() float64 {
 		return float64(ignoredSeriesWithTooLongLabelValue.Load())
 	})
+
+	if tenantOverridesPath == "" {
+		return
+	}
+	tenantOverridesFile = tenantOverridesPath
+	if err := reloadTenantLabelLimits(); err != nil {
+		logger.Fatalf("cannot load -storage.tenantLabelLimitsConfig=%q: %s", tenantOverridesPath, err)
+	}
+	go watchTenantLabelLimitsConfig(tenantOverridesPath)
 }
 
 var (
@@ -60,7 +85,7 @@ var (
 	ignoredSeriesWithTooLongLabelValue atomic.Uint64
 )
 
-func trackIgnoredSeriesWithTooManyLabels(labels []prompbmarshal.Label) {
+func trackIgnoredSeriesWithTooManyLabels(labels []prompbmarshal.Label, maxLabelsPerTimeseries int) {
 	ignoredSeriesWithTooManyLabels.Add(1)
 	select {
 	case <-ignoredSeriesWithTooManyLabelsLogTicker.C:
@@ -73,7 +98,7 @@ func trackIgnoredSeriesWithTooManyLabels(labels []prompbmarshal.Label) {
 	}
 }
 
-func trackIgnoredSeriesWithTooLongLabelValue(l *prompbmarshal.Label, labels []prompbmarshal.Label) {
+func trackIgnoredSeriesWithTooLongLabelValue(l *prompbmarshal.Label, labels []prompbmarshal.Label, maxLabelValueLen int) {
 	ignoredSeriesWithTooLongLabelValue.Add(1)
 	select {
 	case <-ignoredSeriesWithTooLongLabelValueLogTicker.C:
@@ -100,26 +125,219 @@ func trackIgnoredSeriesWithTooLongLabelName(l *prompbmarshal.Label, labels []pro
 	}
 }
 
-// ExceedingLabels checks if passed labels exceed one of the limits:
-// * Maximum allowed labels limit
-// * Maximum allowed label name length limit
-// * Maximum allowed label value length limit
+// TenantLabelLimits contains an override of the global labels limits for a single tenant.
+type TenantLabelLimits struct {
+	AccountID uint32 `yaml:"account_id"`
+	ProjectID uint32 `yaml:"project_id"`
+
+	// MaxLabelsPerTimeseries overrides maxLabelsPerTimeseries for this tenant, if set.
+	MaxLabelsPerTimeseries int `yaml:"max_labels_per_timeseries,omitempty"`
+
+	// MaxLabelValueLen overrides maxLabelValueLen for this tenant, if set.
+	MaxLabelValueLen int `yaml:"max_label_value_len,omitempty"`
+
+	// Mode is either "hard" (the default - ExceedingLabels drops the whole series)
+	// or "soft" (ExceedingLabels truncates oversized names/values and drops the
+	// extra labels instead of dropping the whole series).
+	Mode string `yaml:"mode,omitempty"`
+}
+
+// TenantLabelLimitsConfig is the root object of the -storage.tenantLabelLimitsConfig file.
+type TenantLabelLimitsConfig struct {
+	Overrides []TenantLabelLimits `yaml:"overrides"`
+}
+
+type tenantKey struct {
+	accountID uint32
+	projectID uint32
+}
+
+type resolvedTenantLimits struct {
+	maxLabelsPerTimeseries int
+	maxLabelValueLen       int
+	soft                   bool
+}
+
+var (
+	tenantLimitsMu      sync.Mutex
+	tenantLimits        = map[tenantKey]resolvedTenantLimits{}
+	tenantOverridesFile string
+)
+
+// reloadTenantLabelLimits (re-)reads tenantOverridesFile and atomically swaps
+// the in-memory per-tenant overrides used by ExceedingLabels.
+func reloadTenantLabelLimits() error {
+	data, err := os.ReadFile(tenantOverridesFile)
+	if err != nil {
+		return fmt.Errorf("cannot read tenant label limits config: %w", err)
+	}
+	var cfg TenantLabelLimitsConfig
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return fmt.Errorf("cannot parse tenant label limits config: %w", err)
+	}
+	m := make(map[tenantKey]resolvedTenantLimits, len(cfg.Overrides))
+	for _, o := range cfg.Overrides {
+		rl := resolvedTenantLimits{
+			maxLabelsPerTimeseries: maxLabelsPerTimeseries,
+			maxLabelValueLen:       maxLabelValueLen,
+		}
+		if o.MaxLabelsPerTimeseries > 0 {
+			rl.maxLabelsPerTimeseries = o.MaxLabelsPerTimeseries
+		}
+		if o.MaxLabelValueLen > 0 {
+			rl.maxLabelValueLen = o.MaxLabelValueLen
+		}
+		switch o.Mode {
+		case "", "hard":
+			rl.soft = false
+		case "soft":
+			rl.soft = true
+		default:
+			return fmt.Errorf("unexpected `mode`=%q for tenant %d:%d; supported values are `hard` and `soft`", o.Mode, o.AccountID, o.ProjectID)
+		}
+		m[tenantKey{accountID: o.AccountID, projectID: o.ProjectID}] = rl
+	}
+
+	tenantLimitsMu.Lock()
+	tenantLimits = m
+	tenantLimitsMu.Unlock()
+
+	logger.Infof("loaded %d per-tenant label limits overrides from %q", len(m), tenantOverridesFile)
+	return nil
+}
+
+// watchTenantLabelLimitsConfig reloads the tenant label limits config file
+// every time the process receives SIGHUP, similarly to how other hot-reloadable
+// configs are handled across the rest of the codebase.
+func watchTenantLabelLimitsConfig(path string) {
+	sighupCh := make(chan os.Signal, 1)
+	signal.Notify(sighupCh, syscall.SIGHUP)
+	for range sighupCh {
+		logger.Infof("received SIGHUP; reloading -storage.tenantLabelLimitsConfig=%q", path)
+		if err := reloadTenantLabelLimits(); err != nil {
+			logger.Errorf("cannot reload -storage.tenantLabelLimitsConfig=%q: %s; keeping the previously loaded config", path, err)
+		}
+	}
+}
+
+func effectiveLabelsLimits(accountID, projectID uint32) resolvedTenantLimits {
+	tenantLimitsMu.Lock()
+	rl, ok := tenantLimits[tenantKey{accountID: accountID, projectID: projectID}]
+	tenantLimitsMu.Unlock()
+	if ok {
+		return rl
+	}
+	return resolvedTenantLimits{
+		maxLabelsPerTimeseries: maxLabelsPerTimeseries,
+		maxLabelValueLen:       maxLabelValueLen,
+	}
+}
+
+// ExceedingLabels checks if passed labels exceed one of the limits (global or,
+// if -storage.tenantLabelLimitsConfig defines an override for accountID/projectID,
+// the per-tenant ones):
+//   - Maximum allowed labels limit
+//   - Maximum allowed label name length limit
+//   - Maximum allowed label value length limit
+//
+// In the default "hard" mode it increments metrics, shows a warning in logs
+// and returns the original labels with ok=true, meaning the whole series
+// must be dropped by the caller.
 //
-// increments metrics and shows warning in logs
-func ExceedingLabels(labels []prompbmarshal.Label) bool {
-	if len(labels) > maxLabelsPerTimeseries {
-		trackIgnoredSeriesWithTooManyLabels(labels)
-		return true
+// In "soft" mode (see TenantLabelLimits.Mode) it never asks the caller to drop
+// the series. Instead it returns labels with oversized names/values truncated
+// and, if there are still too many labels, with the extra ones removed
+// (longest values first), incrementing vm_rows_truncated_total instead.
+func ExceedingLabels(accountID, projectID uint32, labels []prompbmarshal.Label) ([]prompbmarshal.Label, bool) {
+	rl := effectiveLabelsLimits(accountID, projectID)
+	if !rl.soft {
+		if len(labels) > rl.maxLabelsPerTimeseries {
+			trackIgnoredSeriesWithTooManyLabels(labels, rl.maxLabelsPerTimeseries)
+			return labels, true
+		}
+		for _, l := range labels {
+			if len(l.Name) > maxLabelNameLen {
+				trackIgnoredSeriesWithTooLongLabelName(&l, labels)
+				return labels, true
+			}
+			if len(l.Value) > rl.maxLabelValueLen {
+				trackIgnoredSeriesWithTooLongLabelValue(&l, labels, rl.maxLabelValueLen)
+				return labels, true
+			}
+		}
+		return labels, false
 	}
-	for _, l := range labels {
+	return truncateExceedingLabels(accountID, projectID, labels, rl), false
+}
+
+func truncateExceedingLabels(accountID, projectID uint32, labels []prompbmarshal.Label, rl resolvedTenantLimits) []prompbmarshal.Label {
+	tenant := fmt.Sprintf("%d:%d", accountID, projectID)
+
+	for i := range labels {
+		l := &labels[i]
 		if len(l.Name) > maxLabelNameLen {
-			trackIgnoredSeriesWithTooLongLabelName(&l, labels)
-			return true
+			l.Name = truncateWithSuffix(l.Name, maxLabelNameLen)
+			rowsTruncatedTotal(tenant, "too_long_label_name").Inc()
+		}
+		if len(l.Value) > rl.maxLabelValueLen {
+			l.Value = truncateWithSuffix(l.Value, rl.maxLabelValueLen)
+			rowsTruncatedTotal(tenant, "too_long_label_value").Inc()
 		}
-		if len(l.Value) > maxLabelValueLen {
-			trackIgnoredSeriesWithTooLongLabelValue(&l, labels)
-			return true
+	}
+
+	if len(labels) <= rl.maxLabelsPerTimeseries {
+		return labels
+	}
+
+	// Drop the extra labels deterministically - the ones with the longest
+	// values are removed first, since they free up the most space while
+	// keeping the shorter, likely more useful labels intact.
+	idxs := make([]int, len(labels))
+	for i := range idxs {
+		idxs[i] = i
+	}
+	sort.SliceStable(idxs, func(a, b int) bool {
+		return len(labels[idxs[a]].Value) > len(labels[idxs[b]].Value)
+	})
+	numToDrop := len(labels) - rl.maxLabelsPerTimeseries
+	dropped := make(map[int]bool, numToDrop)
+	for _, idx := range idxs[:numToDrop] {
+		dropped[idx] = true
+	}
+
+	result := make([]prompbmarshal.Label, 0, rl.maxLabelsPerTimeseries)
+	for i, l := range labels {
+		if dropped[i] {
+			rowsTruncatedTotal(tenant, "too_many_labels").Inc()
+			continue
 		}
+		result = append(result, l)
 	}
-	return false
+	return result
+}
+
+// truncateWithSuffix truncates s to at most maxLen bytes, always cutting on a
+// UTF-8 rune boundary so it never splits a multi-byte rune in half - label
+// values are arbitrary UTF-8 and get stored permanently, so a mid-rune cut
+// would corrupt them rather than just look odd transiently.
+func truncateWithSuffix(s string, maxLen int) string {
+	if maxLen <= 0 {
+		return ""
+	}
+	withSuffix := maxLen > len(truncatedSuffix)
+	cut := maxLen
+	if withSuffix {
+		cut = maxLen - len(truncatedSuffix)
+	}
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	if withSuffix {
+		return s[:cut] + truncatedSuffix
+	}
+	return s[:cut]
+}
+
+func rowsTruncatedTotal(tenant, reason string) *metrics.Counter {
+	return metrics.GetOrCreateCounter(fmt.Sprintf(`vm_rows_truncated_total{reason=%q,tenant=%q}`, reason, tenant))
 }
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+)
+
+func TestExceedingLabelsHardMode(t *testing.T) {
+	maxLabelsPerTimeseries = 3
+	maxLabelValueLen = 8
+	tenantLimits = map[tenantKey]resolvedTenantLimits{}
+
+	f := func(labels []prompbmarshal.Label, wantDrop bool) {
+		t.Helper()
+		gotLabels, drop := ExceedingLabels(0, 0, labels)
+		if drop != wantDrop {
+			t.Fatalf("unexpected drop for %v; got %v; want %v", labels, drop, wantDrop)
+		}
+		if !drop && len(gotLabels) != len(labels) {
+			t.Fatalf("hard mode must not modify labels; got %v; want %v", gotLabels, labels)
+		}
+	}
+
+	f([]prompbmarshal.Label{{Name: "foo", Value: "bar"}}, false)
+	f([]prompbmarshal.Label{
+		{Name: "a", Value: "1"},
+		{Name: "b", Value: "2"},
+		{Name: "c", Value: "3"},
+		{Name: "d", Value: "4"},
+	}, true)
+	f([]prompbmarshal.Label{{Name: "foo", Value: "too-long-value"}}, true)
+}
+
+func TestExceedingLabelsSoftModeTruncatesOnRuneBoundary(t *testing.T) {
+	maxLabelsPerTimeseries = 40
+	maxLabelValueLen = 4096
+	tenantLimits = map[tenantKey]resolvedTenantLimits{
+		{accountID: 1, projectID: 0}: {
+			maxLabelsPerTimeseries: 40,
+			maxLabelValueLen:       10,
+			soft:                   true,
+		},
+	}
+
+	// 9 ASCII bytes followed by a 3-byte rune ('€'); a naive byte-slice cut at
+	// index 10 would land in the middle of the rune and produce invalid UTF-8.
+	value := "123456789€"
+	labels := []prompbmarshal.Label{{Name: "foo", Value: value}}
+
+	gotLabels, drop := ExceedingLabels(1, 0, labels)
+	if drop {
+		t.Fatalf("soft mode must never ask the caller to drop the whole series")
+	}
+	if len(gotLabels) != 1 {
+		t.Fatalf("unexpected number of labels: %v", gotLabels)
+	}
+	got := gotLabels[0].Value
+	if !utf8.ValidString(got) {
+		t.Fatalf("truncated value is not valid UTF-8: %q", got)
+	}
+	if len(got) > 10 {
+		t.Fatalf("truncated value exceeds maxLabelValueLen: %q", got)
+	}
+}
+
+func TestExceedingLabelsSoftModeDropsLongestValuesFirst(t *testing.T) {
+	maxLabelsPerTimeseries = 40
+	maxLabelValueLen = 4096
+	tenantLimits = map[tenantKey]resolvedTenantLimits{
+		{accountID: 2, projectID: 0}: {
+			maxLabelsPerTimeseries: 2,
+			maxLabelValueLen:       4096,
+			soft:                   true,
+		},
+	}
+
+	labels := []prompbmarshal.Label{
+		{Name: "short", Value: "a"},
+		{Name: "medium", Value: "aaaa"},
+		{Name: "long", Value: "aaaaaaaa"},
+	}
+
+	gotLabels, drop := ExceedingLabels(2, 0, labels)
+	if drop {
+		t.Fatalf("soft mode must never ask the caller to drop the whole series")
+	}
+	if len(gotLabels) != 2 {
+		t.Fatalf("unexpected number of remaining labels: %v", gotLabels)
+	}
+	for _, l := range gotLabels {
+		if l.Name == "long" {
+			t.Fatalf("the label with the longest value must be dropped first; got %v", gotLabels)
+		}
+	}
+}
+
+func TestReloadTenantLabelLimits(t *testing.T) {
+	maxLabelsPerTimeseries = 40
+	maxLabelValueLen = 4096
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "overrides.yml")
+	data := `
+overrides:
+  - account_id: 5
+    project_id: 1
+    max_labels_per_timeseries: 10
+    max_label_value_len: 20
+    mode: soft
+  - account_id: 6
+    project_id: 0
+    mode: hard
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("cannot write overrides file: %s", err)
+	}
+
+	tenantOverridesFile = path
+	if err := reloadTenantLabelLimits(); err != nil {
+		t.Fatalf("reloadTenantLabelLimits() failed: %s", err)
+	}
+
+	rl := effectiveLabelsLimits(5, 1)
+	if rl.maxLabelsPerTimeseries != 10 || rl.maxLabelValueLen != 20 || !rl.soft {
+		t.Fatalf("unexpected resolved limits for tenant 5:1: %+v", rl)
+	}
+
+	rl = effectiveLabelsLimits(6, 0)
+	if rl.soft {
+		t.Fatalf("tenant 6:0 must use hard mode: %+v", rl)
+	}
+
+	rl = effectiveLabelsLimits(100, 0)
+	if rl.maxLabelsPerTimeseries != maxLabelsPerTimeseries || rl.maxLabelValueLen != maxLabelValueLen {
+		t.Fatalf("tenant without an override must fall back to the global limits: %+v", rl)
+	}
+}
+
+func TestReloadTenantLabelLimitsRejectsUnknownMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "overrides.yml")
+	data := `
+overrides:
+  - account_id: 1
+    project_id: 0
+    mode: something-else
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("cannot write overrides file: %s", err)
+	}
+
+	tenantOverridesFile = path
+	if err := reloadTenantLabelLimits(); err == nil {
+		t.Fatalf("expecting non-nil error for an unknown `mode`")
+	}
+}